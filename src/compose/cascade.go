@@ -0,0 +1,139 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types/events"
+)
+
+// ExitCodeError is returned by actions that run a container to completion
+// and need to propagate its exit code back to the caller (e.g. main, or the
+// ansible module), as opposed to a regular execution failure.
+type ExitCodeError struct {
+	Container string
+	Code      int
+}
+
+// Error implements the error interface.
+func (e *ExitCodeError) Error() string {
+	return fmt.Sprintf("container %s exited with code %d", e.Container, e.Code)
+}
+
+// attachWithCascade attaches to the given containers and, depending on
+// compose.Cascade, stops the rest of the namespace once one of them exits.
+// When compose.ExitCodeFrom names a service, that container's own exit code
+// is tracked independently of whichever container triggers the cascade, and
+// is what gets returned wrapped as *ExitCodeError - even if some other
+// container happens to die first and trigger the stop.
+func (compose *Compose) attachWithCascade(containers []*Container) error {
+	events, errs := compose.client.Events()
+
+	attachErr := make(chan error, 1)
+	go func() {
+		attachErr <- compose.client.AttachToContainers(containers)
+	}()
+
+	cascading := false
+	exitCodeFromSeen := false
+	var exitCode int
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				if exitCodeFromSeen {
+					return &ExitCodeError{Container: compose.ExitCodeFrom, Code: exitCode}
+				}
+				return <-attachErr
+			}
+			if ev.Action != "die" {
+				continue
+			}
+
+			c := findContainerByID(containers, ev.Actor.ID)
+			if c == nil {
+				continue
+			}
+
+			code := exitCodeFromEvent(ev)
+
+			if compose.ExitCodeFrom != "" && c.Name.Name == compose.ExitCodeFrom {
+				exitCode = code
+				exitCodeFromSeen = true
+			}
+
+			if !cascading {
+				shouldCascade := compose.Cascade == CascadeStop ||
+					(compose.Cascade == CascadeFail && code != 0)
+
+				if shouldCascade {
+					cascading = true
+					log.Infof("Cascading stop: %s exited with code %d, stopping the rest of the namespace", c.Name, code)
+					if err := compose.client.StopContainers(containers); err != nil {
+						log.Warnf("Failed to stop containers during cascade, error: %s", err)
+					}
+				}
+			}
+
+			// Once we know the ExitCodeFrom container's own exit code, we're
+			// done - that container finishing is "done" for a one-shot job
+			// runner regardless of whether a cascade ever triggered (e.g.
+			// CascadeFail when it happens to exit 0). Otherwise, if no
+			// ExitCodeFrom was requested, a triggered cascade is itself the
+			// stop condition. If ExitCodeFrom is set but hasn't died yet
+			// (some other container triggered the cascade first), keep
+			// waiting: stopping it above will produce its own "die" event.
+			if exitCodeFromSeen {
+				return &ExitCodeError{Container: compose.ExitCodeFrom, Code: exitCode}
+			}
+			if cascading && compose.ExitCodeFrom == "" {
+				return nil
+			}
+		case err, ok := <-errs:
+			if !ok {
+				if exitCodeFromSeen {
+					return &ExitCodeError{Container: compose.ExitCodeFrom, Code: exitCode}
+				}
+				return <-attachErr
+			}
+			return fmt.Errorf("cascade: events stream error: %s", err)
+		}
+	}
+}
+
+func findContainerByID(containers []*Container, id string) *Container {
+	for _, c := range containers {
+		if c.Id == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// exitCodeFromEvent reads the "exitCode" attribute Docker attaches to "die"
+// events, defaulting to 0 if it is missing or malformed.
+func exitCodeFromEvent(ev events.Message) int {
+	code, err := strconv.Atoi(ev.Actor.Attributes["exitCode"])
+	if err != nil {
+		return 0
+	}
+	return code
+}
@@ -0,0 +1,70 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"io"
+	"time"
+
+	"compose/config"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+)
+
+// Client is the interface compose uses to talk to the Docker daemon. It is
+// implemented by DockerClient; tests and DryRunner deal with the Action
+// abstraction instead and never need a real Client.
+type Client interface {
+	GetContainers() ([]*Container, error)
+
+	// FetchImages pulls whichever of containers' images aren't already
+	// present locally, reporting progress for each one through reporter and
+	// bounding how many pull concurrently to parallelism (0 means
+	// unlimited) - the same contract pullImagesConcurrently gives PullImage
+	// callers in progress.go.
+	FetchImages(containers []*Container, reporter ProgressReporter, parallelism int) error
+	AttachToContainers(containers []*Container) error
+	PullAll(manifest *config.Config) error
+	Clean(manifest *config.Config) error
+	GetPulledImages() []ImageName
+	GetRemovedImages() []ImageName
+
+	// Events streams Docker daemon events for the duration of the process;
+	// used by LogsAction's follow mode and cascade monitoring.
+	Events() (<-chan events.Message, <-chan error)
+	ContainerLogs(id string, opts types.ContainerLogsOptions) (io.ReadCloser, error)
+	InspectContainer(id string) (*Container, error)
+
+	StopContainers(containers []*Container) error
+	StopContainer(container *Container, timeout time.Duration) error
+	StartContainer(container *Container) error
+
+	// CreateContainer creates a container for the given spec without
+	// starting it; used by CreateAction to stage a deploy ahead of time.
+	CreateContainer(container *Container) error
+
+	// PullImage pulls a single named image and returns its raw progress
+	// stream, so callers can report per-layer progress themselves.
+	PullImage(name string) (io.ReadCloser, error)
+
+	ContainerExecCreate(containerID string, config types.ExecConfig) (string, error)
+	ContainerExecStart(execID string, opts types.ExecStartCheck) error
+	ContainerExecAttach(execID string, opts types.ExecStartCheck) (types.HijackedResponse, error)
+	ContainerExecInspect(execID string) (types.ContainerExecInspect, error)
+	ContainerExecResize(execID string, height, width uint) error
+}
@@ -24,6 +24,7 @@ import (
 	"compose/ansible"
 	"compose/config"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -35,28 +36,56 @@ import (
 // ComposeConfig is a configuration object which is passed to compose.New()
 // for creating the new Compose instance.
 type ComposeConfig struct {
-	Manifest   *config.Config
-	Docker     *docker.Client
-	Global     bool
-	Force      bool
-	DryRun     bool
-	Attach     bool
-	Pull       bool
-	Remove     bool
-	Recover    bool
-	Wait       time.Duration
-	Auth       *AuthConfig
-	KeepImages int
+	Manifest        *config.Config
+	Docker          *docker.Client
+	Global          bool
+	Force           bool
+	DryRun          bool
+	Attach          bool
+	Pull            bool
+	Remove          bool
+	Recover         bool
+	Wait            time.Duration
+	Auth            *AuthConfig
+	KeepImages      int
+	Cascade         Cascade
+	ExitCodeFrom    string
+	Progress        ProgressReporter
+	PlanFormat      string
+	PlanWriter      io.Writer
+	PullParallelism int
 }
 
+// Cascade controls how RunAction reacts to containers exiting while it is
+// attached, mirroring docker compose's --abort-on-container-exit/-failure.
+type Cascade int
+
+const (
+	// CascadeIgnore leaves containers running regardless of what their
+	// siblings do. This is the default, pre-existing behavior.
+	CascadeIgnore Cascade = iota
+	// CascadeStop stops every other container in the namespace as soon as
+	// any watched container exits, regardless of its exit code.
+	CascadeStop
+	// CascadeFail only stops the rest of the namespace when a watched
+	// container exits with a non-zero status.
+	CascadeFail
+)
+
 // Compose is the main object that executes actions and holds runtime information.
 type Compose struct {
-	Manifest *config.Config
-	DryRun   bool
-	Attach   bool
-	Pull     bool
-	Remove   bool
-	Wait     time.Duration
+	Manifest        *config.Config
+	DryRun          bool
+	Attach          bool
+	Pull            bool
+	Remove          bool
+	Wait            time.Duration
+	Cascade         Cascade
+	ExitCodeFrom    string
+	PlanFormat      string
+	PlanWriter      io.Writer
+	Progress        ProgressReporter
+	PullParallelism int
 
 	client             Client
 	chErrors           chan error
@@ -67,12 +96,18 @@ type Compose struct {
 // New makes a new Compose object
 func New(config *ComposeConfig) (*Compose, error) {
 	compose := &Compose{
-		Manifest: config.Manifest,
-		DryRun:   config.DryRun,
-		Attach:   config.Attach,
-		Pull:     config.Pull,
-		Wait:     config.Wait,
-		Remove:   config.Remove,
+		Manifest:        config.Manifest,
+		DryRun:          config.DryRun,
+		Attach:          config.Attach,
+		Pull:            config.Pull,
+		Wait:            config.Wait,
+		Remove:          config.Remove,
+		Cascade:         config.Cascade,
+		ExitCodeFrom:    config.ExitCodeFrom,
+		PlanFormat:      config.PlanFormat,
+		PlanWriter:      config.PlanWriter,
+		Progress:        progressReporterOrNoop(config.Progress),
+		PullParallelism: config.PullParallelism,
 	}
 
 	cliConf := &DockerClient{
@@ -83,6 +118,7 @@ func New(config *ComposeConfig) (*Compose, error) {
 		Auth:       config.Auth,
 		KeepImages: config.KeepImages,
 		Recover:    config.Recover,
+		Progress:   progressReporterOrNoop(config.Progress),
 	}
 
 	cli, err := NewClient(cliConf)
@@ -119,7 +155,7 @@ func (compose *Compose) RunAction() error {
 	}
 
 	// fetch missing images for containers needed to be started
-	if err := compose.client.FetchImages(expected); err != nil {
+	if err := compose.client.FetchImages(expected, progressReporterOrNoop(compose.Progress), compose.PullParallelism); err != nil {
 		return fmt.Errorf("Failed to fetch images of given containers, error: %s", err)
 	}
 
@@ -138,6 +174,16 @@ func (compose *Compose) RunAction() error {
 	}
 	compose.executionPlan = executionPlan
 
+	if compose.PlanFormat != "" && compose.PlanFormat != "text" {
+		if compose.PlanWriter == nil {
+			return fmt.Errorf("ComposeConfig.PlanWriter is required when PlanFormat is %q", compose.PlanFormat)
+		}
+		plan := BuildPlan(compose.Manifest.Namespace, executionPlan)
+		if err := WritePlanFormat(compose.PlanWriter, plan, compose.PlanFormat); err != nil {
+			return fmt.Errorf("Failed to write execution plan, error: %s", err)
+		}
+	}
+
 	var runner Runner
 	if compose.DryRun {
 		runner = NewDryRunner()
@@ -165,6 +211,11 @@ func (compose *Compose) RunAction() error {
 	// if --attach was specified
 	if compose.Attach {
 		log.Debugf("Attaching to containers...")
+
+		if compose.Cascade != CascadeIgnore {
+			return compose.attachWithCascade(expected)
+		}
+
 		if err := compose.client.AttachToContainers(expected); err != nil {
 			return fmt.Errorf("Cannot attach to containers, error: %s", err)
 		}
@@ -227,9 +278,35 @@ func (compose *Compose) RecoverAction() error {
 	return nil
 }
 
+// ImageNamesFromConfig collects the distinct image names referenced by
+// manifest, in the order their containers first appear, for use with
+// pullImagesConcurrently.
+func ImageNamesFromConfig(manifest *config.Config) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, c := range GetContainersFromConfig(manifest) {
+		if c.Image == "" || seen[c.Image] {
+			continue
+		}
+		seen[c.Image] = true
+		names = append(names, c.Image)
+	}
+	return names
+}
+
 // PullAction implements 'rocker-compose pull'
 func (compose *Compose) PullAction() error {
-	if err := compose.client.PullAll(compose.Manifest); err != nil {
+	reporter := progressReporterOrNoop(compose.Progress)
+
+	err := pullImagesConcurrently(ImageNamesFromConfig(compose.Manifest), compose.PullParallelism, reporter, func(name string, reporter ProgressReporter) error {
+		stream, err := compose.client.PullImage(name)
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+		return reportPullProgress(reporter, name, stream)
+	})
+	if err != nil {
 		return fmt.Errorf("Failed to pull all images, error: %s", err)
 	}
 
@@ -251,6 +328,7 @@ func (compose *Compose) CleanAction() error {
 func (compose *Compose) WritePlan(resp *ansible.Response) *ansible.Response {
 	resp.Removed = []ansible.ResponseContainer{}
 	resp.Created = []ansible.ResponseContainer{}
+	resp.Restarted = []ansible.ResponseContainer{}
 	resp.Pulled = []string{}
 	resp.Cleaned = []string{}
 
@@ -267,6 +345,18 @@ func (compose *Compose) WritePlan(resp *ansible.Response) *ansible.Response {
 				Name: a.container.Name.String(),
 			})
 		}
+		if a, ok := action.(*createContainer); ok {
+			resp.Created = append(resp.Created, ansible.ResponseContainer{
+				Id:   a.container.Id,
+				Name: a.container.Name.String(),
+			})
+		}
+		if a, ok := action.(*restartContainer); ok {
+			resp.Restarted = append(resp.Restarted, ansible.ResponseContainer{
+				Id:   a.container.Id,
+				Name: a.container.Name.String(),
+			})
+		}
 	})
 
 	// TODO: images are pulled but may not be changed
@@ -278,6 +368,6 @@ func (compose *Compose) WritePlan(resp *ansible.Response) *ansible.Response {
 		resp.Cleaned = append(resp.Cleaned, imageName.String())
 	}
 
-	resp.Changed = len(resp.Removed)+len(resp.Created)+len(resp.Pulled) > 0
+	resp.Changed = len(resp.Removed)+len(resp.Created)+len(resp.Restarted)+len(resp.Pulled) > 0
 	return resp
 }
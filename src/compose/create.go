@@ -0,0 +1,229 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"strings"
+
+	"compose/util"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// CreateOptions configures CreateAction.
+type CreateOptions struct {
+	// ForceRecreate bypasses the IsSameKind id assignment so every
+	// container is created fresh, even if an equivalent one already exists.
+	ForceRecreate bool
+	// NoRecreate keeps existing containers even if their config drifted
+	// from the manifest, by skipping the removeContainer/createContainer
+	// pair CreateAction builds for a container that already exists - only
+	// genuinely missing containers get created. Mutually exclusive with
+	// ForceRecreate.
+	NoRecreate bool
+	// Build is reserved for future rocker-build integration.
+	Build bool
+}
+
+// CreateAction implements 'rocker-compose create'
+//
+// It runs the same diff RunAction does, but only creates the resulting
+// containers - it never starts them - so the deploy can be staged ahead of
+// time and started later, e.g. with RestartAction or a follow-up RunAction.
+//
+// Diff's plan has no action for "create but don't start": its *runContainer
+// steps create and start in one go. So CreateAction doesn't run Diff's plan
+// through the generic Runner at all - it collects the plan's
+// *removeContainer and *runContainer steps itself, executes the removals exactly
+// like RunAction does, and rewrites every runContainer into a createContainer
+// step of its own that it executes directly, bypassing runContainer's start
+// phase entirely.
+func (compose *Compose) CreateAction(opts CreateOptions) error {
+	if opts.ForceRecreate && opts.NoRecreate {
+		return fmt.Errorf("CreateAction: ForceRecreate and NoRecreate are mutually exclusive")
+	}
+
+	actual, err := compose.client.GetContainers()
+	if err != nil {
+		return fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	expected := GetContainersFromConfig(compose.Manifest)
+
+	if err := compose.client.FetchImages(expected, progressReporterOrNoop(compose.Progress), compose.PullParallelism); err != nil {
+		return fmt.Errorf("Failed to fetch images of given containers, error: %s", err)
+	}
+
+	// Assign IDs of existing containers, same as RunAction does. ForceRecreate
+	// skips this so Diff treats every expected container as missing.
+	if !opts.ForceRecreate {
+		for _, actualC := range actual {
+			for _, expectedC := range expected {
+				if expectedC.IsSameKind(actualC) {
+					expectedC.Id = actualC.Id
+				}
+			}
+		}
+	}
+
+	diffPlan, err := NewDiff(compose.Manifest.Namespace).Diff(expected, actual)
+	if err != nil {
+		return fmt.Errorf("Diff of configuration failed, error: %s", err)
+	}
+
+	removals, creates := splitCreatePlan(diffPlan, actual)
+
+	if opts.NoRecreate {
+		removals, creates = dropRecreatesOfExistingContainers(removals, creates, actual)
+	}
+
+	executionPlan := append(append([]Action{}, removals...), creates...)
+	compose.executionPlan = executionPlan
+
+	if compose.DryRun {
+		if err := NewDryRunner().Run(executionPlan); err != nil {
+			return fmt.Errorf("Execution failed with, error: %s", err)
+		}
+		return nil
+	}
+
+	if err := NewDockerClientRunner(compose.client).Run(removals); err != nil {
+		return fmt.Errorf("Execution failed with, error: %s", err)
+	}
+
+	wg := util.NewErrorWaitGroup(len(creates))
+	for _, action := range creates {
+		a := action.(*createContainer)
+		go func() {
+			wg.Done(a.Execute(compose.client))
+		}()
+	}
+	if err := wg.Wait(); err != nil {
+		return fmt.Errorf("Execution failed with, error: %s", err)
+	}
+
+	strContainers := []string{}
+	for _, c := range expected {
+		strContainers = append(strContainers, c.Name.String())
+	}
+	if len(strContainers) > 0 {
+		log.Infof("OK, containers are created: %s", strings.Join(strContainers, ", "))
+	} else {
+		log.Infof("Nothing to create")
+	}
+
+	return nil
+}
+
+// splitCreatePlan separates a Diff plan into its removeContainer steps,
+// executed as-is, and its runContainer steps, rewritten into createContainer
+// so CreateAction can create them without ever triggering runContainer's
+// start phase. actual is consulted so a createContainer replacing an
+// existing container of the same id can record that container's image,
+// for Describe to tell "image changed" apart from other config drift.
+//
+// It goes through WalkActions, which recurses into nested dependency/parallel
+// groups, so steps nested inside them are found too. The grouping itself is
+// not kept: CreateAction runs all removals first, then all creates in parallel.
+func splitCreatePlan(plan []Action, actual []*Container) (removals []Action, creates []Action) {
+	actualByID := map[string]*Container{}
+	for _, c := range actual {
+		if c.Id != "" {
+			actualByID[c.Id] = c
+		}
+	}
+
+	WalkActions(plan, func(action Action) {
+		switch a := action.(type) {
+		case *removeContainer:
+			removals = append(removals, a)
+		case *runContainer:
+			create := &createContainer{container: a.container}
+			if prev, ok := actualByID[a.container.Id]; ok {
+				create.previousImage = prev.Image
+			}
+			creates = append(creates, create)
+		}
+	})
+	return removals, creates
+}
+
+// dropRecreatesOfExistingContainers drops both halves of a recreate pair -
+// the removeContainer targeting an existing container and the matching
+// createContainer that would immediately take its place - so
+// CreateOptions.NoRecreate can leave a drifted container running untouched
+// instead of colliding with it under the same name.
+//
+// expectedC.Id is assigned from the matching actual container before Diff
+// runs (see CreateAction), so a createContainer produced for a drifted
+// container carries that same Id; matching on it is what pairs the two
+// halves back up.
+func dropRecreatesOfExistingContainers(removals, creates []Action, actual []*Container) (filteredRemovals, filteredCreates []Action) {
+	existingIDs := map[string]bool{}
+	for _, c := range actual {
+		if c.Id != "" {
+			existingIDs[c.Id] = true
+		}
+	}
+
+	droppedIDs := map[string]bool{}
+	for _, action := range creates {
+		if a, ok := action.(*createContainer); ok && a.container.Id != "" && existingIDs[a.container.Id] {
+			droppedIDs[a.container.Id] = true
+			continue
+		}
+		filteredCreates = append(filteredCreates, action)
+	}
+
+	for _, action := range removals {
+		if a, ok := action.(*removeContainer); ok && droppedIDs[a.container.Id] {
+			continue
+		}
+		filteredRemovals = append(filteredRemovals, action)
+	}
+
+	return filteredRemovals, filteredCreates
+}
+
+// createContainer is an Action that creates a container without starting
+// it. Nothing in Diff ever produces one - CreateAction builds these itself
+// from the runContainer steps in a Diff plan - so it's executed directly
+// rather than through the generic Runner.
+type createContainer struct {
+	container *Container
+	// previousImage is the image of the existing container this one would
+	// replace, if any; empty when the container is genuinely new. Set by
+	// splitCreatePlan, and used by Describe to report "image_changed"
+	// specifically instead of the generic "config_changed".
+	previousImage string
+}
+
+// String returns a human readable representation of the action, used by DryRunner.
+func (a *createContainer) String() string {
+	return fmt.Sprintf("Create container %s (staged, not started)", a.container.Name)
+}
+
+// Execute implements Action. CreateAction calls this directly, in parallel
+// via a util.ErrorWaitGroup, since runContainer.Execute would also start
+// the container, which CreateAction must not do.
+func (a *createContainer) Execute(client Client) error {
+	if err := client.CreateContainer(a.container); err != nil {
+		return fmt.Errorf("failed to create container %s, error: %s", a.container.Name, err)
+	}
+	return nil
+}
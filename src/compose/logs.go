@@ -0,0 +1,216 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"compose/util"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// LogConsumer receives demuxed log output and status messages while
+// LogsAction is streaming. Implementations decide how to render them,
+// e.g. to a terminal, a file, or back over an RPC channel.
+type LogConsumer interface {
+	// Log is called for every line (or chunk) of stdout/stderr produced
+	// by a container.
+	Log(container, service, msg string)
+	// Status is called for informational messages that don't belong to
+	// any particular log stream, such as "waiting for container to start".
+	Status(container, msg string)
+}
+
+// LogsOptions configures LogsAction.
+type LogsOptions struct {
+	// Services restricts streaming to the given service names; empty means all.
+	Services   []string
+	Follow     bool
+	Tail       string
+	Since      time.Time
+	Until      time.Time
+	Timestamps bool
+	Consumer   LogConsumer
+}
+
+// LogsAction implements 'rocker-compose logs'
+//
+// It resolves the containers belonging to the current manifest namespace,
+// opens a log stream for each of them in parallel and multiplexes the
+// demuxed output into opts.Consumer. In follow mode it also watches the
+// Docker events stream so containers that get (re)started after LogsAction
+// begins are picked up without missing any lines.
+func (compose *Compose) LogsAction(opts LogsOptions) error {
+	if opts.Consumer == nil {
+		return fmt.Errorf("LogsAction: Consumer is required")
+	}
+
+	containers, err := compose.client.GetContainers()
+	if err != nil {
+		return fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	containers = filterContainersByNamespaceAndServices(containers, compose.Manifest.Namespace, opts.Services)
+	if len(containers) == 0 {
+		log.Infof("No containers found for namespace %s", compose.Manifest.Namespace)
+		return nil
+	}
+
+	wg := util.NewErrorWaitGroup(len(containers))
+
+	for _, c := range containers {
+		go func(c *Container) {
+			wg.Done(compose.streamContainerLogs(c, opts, opts.Since))
+		}(c)
+	}
+
+	// Containers picked up later by watchNewContainersForLogs aren't added to
+	// wg: they arrive after Wait could already have been called. Instead,
+	// follow mode doesn't return when the initial streams close: it keeps
+	// running until the events watcher itself ends.
+	if !opts.Follow {
+		return wg.Wait()
+	}
+
+	watchDone := make(chan struct{})
+	go func() {
+		compose.watchNewContainersForLogs(opts)
+		close(watchDone)
+	}()
+
+	if err := wg.Wait(); err != nil {
+		return err
+	}
+	<-watchDone
+	return nil
+}
+
+// streamContainerLogs attaches to a single container's log stream and
+// forwards demuxed output to opts.Consumer until the stream closes (or
+// forever, if opts.Follow is set).
+func (compose *Compose) streamContainerLogs(c *Container, opts LogsOptions, since time.Time) error {
+	logOpts := types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     opts.Follow,
+		Tail:       opts.Tail,
+		Timestamps: opts.Timestamps,
+	}
+	if !since.IsZero() {
+		logOpts.Since = since.Format(time.RFC3339Nano)
+	}
+	if !opts.Until.IsZero() {
+		logOpts.Until = opts.Until.Format(time.RFC3339Nano)
+	}
+
+	reader, err := compose.client.ContainerLogs(c.Id, logOpts)
+	if err != nil {
+		if errdefs.IsNotImplemented(err) {
+			opts.Consumer.Status(c.Id, fmt.Sprintf("logs not available for %s, container uses the 'none' log driver", c.Name))
+			return nil
+		}
+		return fmt.Errorf("failed to open log stream for %s, error: %s", c.Name, err)
+	}
+	defer reader.Close()
+
+	outW := logWriter{consumer: opts.Consumer, container: c.Id, service: c.Name.String()}
+	errW := logWriter{consumer: opts.Consumer, container: c.Id, service: c.Name.String()}
+
+	if _, err := stdcopy.StdCopy(outW, errW, reader); err != nil && err != io.EOF {
+		return fmt.Errorf("log stream for %s ended with error: %s", c.Name, err)
+	}
+	return nil
+}
+
+// watchNewContainersForLogs listens to the Docker events stream and attaches
+// a new log stream as soon as a container matching the manifest namespace
+// starts, beginning at the event's own timestamp so no lines are missed or
+// duplicated.
+func (compose *Compose) watchNewContainersForLogs(opts LogsOptions) {
+	events, errs := compose.client.Events()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if ev.Action != "start" {
+				continue
+			}
+			c, err := compose.client.InspectContainer(ev.Actor.ID)
+			if err != nil || !containerMatchesNamespaceAndServices(c, compose.Manifest.Namespace, opts.Services) {
+				continue
+			}
+			since := time.Unix(0, ev.TimeNano)
+			go func(c *Container) {
+				if err := compose.streamContainerLogs(c, opts, since); err != nil {
+					opts.Consumer.Status(c.Id, fmt.Sprintf("log stream error: %s", err))
+				}
+			}(c)
+		case err, ok := <-errs:
+			if !ok {
+				return
+			}
+			log.Warnf("LogsAction: events stream error: %s", err)
+			return
+		}
+	}
+}
+
+type logWriter struct {
+	consumer  LogConsumer
+	container string
+	service   string
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.consumer.Log(w.container, w.service, string(p))
+	return len(p), nil
+}
+
+// filterContainersByNamespaceAndServices narrows a container list down to
+// the given namespace and, if non-empty, the given service name allowlist.
+func filterContainersByNamespaceAndServices(containers []*Container, namespace string, services []string) []*Container {
+	result := []*Container{}
+	for _, c := range containers {
+		if containerMatchesNamespaceAndServices(c, namespace, services) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func containerMatchesNamespaceAndServices(c *Container, namespace string, services []string) bool {
+	if c.Name.Namespace != namespace {
+		return false
+	}
+	if len(services) == 0 {
+		return true
+	}
+	for _, s := range services {
+		if c.Name.Name == s {
+			return true
+		}
+	}
+	return false
+}
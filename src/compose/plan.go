@@ -0,0 +1,95 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// PlanAction describes a single planned change in a stable, marshalable
+// shape, independent of the concrete Action implementation that produced it.
+//
+// DependsOn is part of the schema but currently always empty: populating it
+// needs the manifest's container dependency graph (links/depends_on), which
+// doesn't reach any Describe() implementation today - see plan_describe.go.
+type PlanAction struct {
+	Type      string           `json:"type" yaml:"type"`
+	Container PlanContainerRef `json:"container" yaml:"container"`
+	Reason    string           `json:"reason" yaml:"reason"`
+	DependsOn []string         `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
+}
+
+// PlanContainerRef identifies the container a PlanAction applies to.
+type PlanContainerRef struct {
+	Name  string `json:"name" yaml:"name"`
+	Id    string `json:"id,omitempty" yaml:"id,omitempty"`
+	Image string `json:"image,omitempty" yaml:"image,omitempty"`
+}
+
+// Plan is the top-level document written by WritePlanFormat in machine-readable
+// formats, e.g. `rocker-compose run --dry-run --format=json`.
+type Plan struct {
+	Namespace string       `json:"namespace" yaml:"namespace"`
+	Actions   []PlanAction `json:"actions" yaml:"actions"`
+}
+
+// Describer is implemented by Action types that can explain themselves in a
+// Plan, e.g. for --dry-run --format=json/yaml output.
+type Describer interface {
+	Describe() PlanAction
+}
+
+// BuildPlan walks an execution plan and collects a Plan describing it. Action
+// implementations that don't implement Describer are skipped, since WalkActions
+// also visits purely structural nodes (like dependency barriers) that have
+// nothing of their own to describe.
+func BuildPlan(namespace string, executionPlan []Action) Plan {
+	plan := Plan{Namespace: namespace, Actions: []PlanAction{}}
+	WalkActions(executionPlan, func(action Action) {
+		if d, ok := action.(Describer); ok {
+			plan.Actions = append(plan.Actions, d.Describe())
+		}
+	})
+	return plan
+}
+
+// WritePlanFormat marshals a Plan to w according to format ("json" or
+// "yaml"). "text" and "" are a no-op here, since the existing DryRunner
+// already prints a human-readable plan; any other value is an error.
+func WritePlanFormat(w io.Writer, plan Plan, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	case "yaml":
+		out, err := yaml.Marshal(plan)
+		if err != nil {
+			return fmt.Errorf("failed to marshal plan to yaml, error: %s", err)
+		}
+		_, err = w.Write(out)
+		return err
+	case "", "text":
+		return nil
+	default:
+		return fmt.Errorf("unknown plan format %q, expected one of: text, json, yaml", format)
+	}
+}
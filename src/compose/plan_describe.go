@@ -0,0 +1,91 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+// Describe implements Describer for runContainer, the most common action in
+// a RunAction plan - a container that needs to be (re)started. container.Id
+// is only set once a pre-existing container of the same kind was matched in
+// RunAction's id-assignment pass, so its presence distinguishes a fresh
+// container from one being recreated because its config drifted.
+//
+// The reason does not carry per-field detail (image_changed,
+// config_changed: Env, ...), and DependsOn is left empty: both live in
+// Diff's own comparison and the manifest's dependency graph (container
+// links/depends_on), and Diff does not record either on the runContainer it
+// emits, so neither can be reconstructed from a *Container alone after the
+// fact. "missing" vs "config_changed" is what's derivable from the
+// container alone.
+func (a *runContainer) Describe() PlanAction {
+	reason := "missing"
+	if a.container.Id != "" {
+		reason = "config_changed"
+	}
+	return PlanAction{
+		Type:   "run",
+		Reason: reason,
+		Container: PlanContainerRef{
+			Name:  a.container.Name.String(),
+			Id:    a.container.Id,
+			Image: a.container.Image,
+		},
+	}
+}
+
+// Describe implements Describer for createContainer, CreateAction's own
+// stand-in for runContainer when staging a deploy without starting it.
+//
+// Unlike runContainer, createContainer is built by this package (see
+// splitCreatePlan), so it can and does carry the one extra bit of detail
+// that's cheap to capture at construction time: the image of the container
+// it's replacing, letting Describe tell "image changed" apart from other
+// config drift. DependsOn is still left empty - CreateAction has no more
+// access to the manifest's dependency graph than runContainer does.
+func (a *createContainer) Describe() PlanAction {
+	reason := "missing"
+	switch {
+	case a.container.Id == "":
+		reason = "missing"
+	case a.previousImage != "" && a.previousImage != a.container.Image:
+		reason = "image_changed"
+	default:
+		reason = "config_changed"
+	}
+	return PlanAction{
+		Type:   "create",
+		Reason: reason,
+		Container: PlanContainerRef{
+			Name:  a.container.Name.String(),
+			Id:    a.container.Id,
+			Image: a.container.Image,
+		},
+	}
+}
+
+// Describe implements Describer for removeContainer - a container that's no
+// longer in the manifest, or is about to be replaced by one with the same
+// name but drifted config.
+func (a *removeContainer) Describe() PlanAction {
+	return PlanAction{
+		Type:   "remove",
+		Reason: "stale",
+		Container: PlanContainerRef{
+			Name:  a.container.Name.String(),
+			Id:    a.container.Id,
+			Image: a.container.Image,
+		},
+	}
+}
@@ -0,0 +1,207 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"compose/util"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// pullImagesConcurrently pulls every image in names using pull to do the
+// actual work, bounding concurrency to parallelism (0 means unlimited) via
+// util.BoundedErrorWaitGroup, and reporting progress for each image through
+// reporter. Both PullAction and Client.FetchImages are built on this instead
+// of a sequential pull loop.
+func pullImagesConcurrently(names []string, parallelism int, reporter ProgressReporter, pull func(name string, reporter ProgressReporter) error) error {
+	reporter = progressReporterOrNoop(reporter)
+
+	wg := util.NewBoundedErrorWaitGroup(len(names), parallelism)
+
+	for _, name := range names {
+		name := name
+		wg.Go(func() error {
+			reporter.Start(name, "pulling")
+			err := pull(name, reporter)
+			reporter.Done(name, err)
+			return err
+		})
+	}
+
+	return wg.Wait()
+}
+
+// ProgressReporter receives progress updates while images are being pulled
+// or fetched, so callers can render them however suits their environment
+// (a TTY, a CI log, a structured event stream).
+type ProgressReporter interface {
+	// Start is called once per image, before any data has been transferred.
+	Start(id, description string)
+	// Update is called for every layer-level progress event for an image.
+	// total may be 0 if the registry didn't advertise a size.
+	Update(id string, current, total int64, status string)
+	// Done is called once per image, with a non-nil err if the pull failed.
+	Done(id string, err error)
+}
+
+// noopProgressReporter is used when ComposeConfig.Progress is left nil, so
+// callers elsewhere don't have to nil-check before calling it.
+type noopProgressReporter struct{}
+
+func (noopProgressReporter) Start(id, description string)                          {}
+func (noopProgressReporter) Update(id string, current, total int64, status string) {}
+func (noopProgressReporter) Done(id string, err error)                             {}
+
+// TTYProgressReporter renders one line per image and redraws them in place
+// using ANSI cursor-up sequences, the way docker/compose's build/pull
+// progress printer does.
+type TTYProgressReporter struct {
+	Out io.Writer
+
+	mu    sync.Mutex
+	order []string
+	lines map[string]string
+	drawn int
+}
+
+// NewTTYProgressReporter makes a TTYProgressReporter that writes to out.
+func NewTTYProgressReporter(out io.Writer) *TTYProgressReporter {
+	return &TTYProgressReporter{
+		Out:   out,
+		lines: map[string]string{},
+	}
+}
+
+// Start implements ProgressReporter.
+func (r *TTYProgressReporter) Start(id, description string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.lines[id]; !ok {
+		r.order = append(r.order, id)
+	}
+	r.lines[id] = fmt.Sprintf("%s: %s", id, description)
+	r.redraw()
+}
+
+// Update implements ProgressReporter.
+func (r *TTYProgressReporter) Update(id string, current, total int64, status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if total > 0 {
+		r.lines[id] = fmt.Sprintf("%s: %s %d/%d", id, status, current, total)
+	} else {
+		r.lines[id] = fmt.Sprintf("%s: %s", id, status)
+	}
+	r.redraw()
+}
+
+// Done implements ProgressReporter.
+func (r *TTYProgressReporter) Done(id string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.lines[id] = fmt.Sprintf("%s: error: %s", id, err)
+	} else {
+		r.lines[id] = fmt.Sprintf("%s: done", id)
+	}
+	r.redraw()
+}
+
+// redraw assumes r.mu is held. It moves the cursor back up to the top of
+// the block it drew last time and re-prints every line.
+func (r *TTYProgressReporter) redraw() {
+	if r.drawn > 0 {
+		fmt.Fprintf(r.Out, "\033[%dA", r.drawn)
+	}
+	for _, id := range r.order {
+		fmt.Fprintf(r.Out, "\033[2K%s\n", r.lines[id])
+	}
+	r.drawn = len(r.order)
+}
+
+// PlainProgressReporter emits one line per status change, suitable for
+// non-TTY output such as CI logs or the ansible module's captured stdout.
+type PlainProgressReporter struct {
+	Out io.Writer
+}
+
+// NewPlainProgressReporter makes a PlainProgressReporter that writes to out.
+func NewPlainProgressReporter(out io.Writer) *PlainProgressReporter {
+	return &PlainProgressReporter{Out: out}
+}
+
+// Start implements ProgressReporter.
+func (r *PlainProgressReporter) Start(id, description string) {
+	fmt.Fprintf(r.Out, "Pulling %s: %s\n", id, description)
+}
+
+// Update implements ProgressReporter.
+func (r *PlainProgressReporter) Update(id string, current, total int64, status string) {
+	if total > 0 {
+		fmt.Fprintf(r.Out, "Pulling %s: %s (%d/%d)\n", id, status, current, total)
+	} else {
+		fmt.Fprintf(r.Out, "Pulling %s: %s\n", id, status)
+	}
+}
+
+// Done implements ProgressReporter.
+func (r *PlainProgressReporter) Done(id string, err error) {
+	if err != nil {
+		fmt.Fprintf(r.Out, "Pulling %s: failed, error: %s\n", id, err)
+		return
+	}
+	fmt.Fprintf(r.Out, "Pulling %s: done\n", id)
+}
+
+// pullProgressLine is the shape of a single JSON object on Docker's image
+// pull stream, the subset of fields we care about for progress reporting.
+type pullProgressLine struct {
+	Status         string `json:"status"`
+	ID             string `json:"id"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+}
+
+// reportPullProgress decodes a Docker pull stream and forwards layer-level
+// updates to reporter, tagged under imageID.
+func reportPullProgress(reporter ProgressReporter, imageID string, stream io.Reader) error {
+	dec := json.NewDecoder(stream)
+	for {
+		var line pullProgressLine
+		if err := dec.Decode(&line); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		reporter.Update(imageID, line.ProgressDetail.Current, line.ProgressDetail.Total, line.Status)
+	}
+}
+
+func progressReporterOrNoop(r ProgressReporter) ProgressReporter {
+	if r == nil {
+		log.Debugf("No ProgressReporter configured, progress output will be suppressed")
+		return noopProgressReporter{}
+	}
+	return r
+}
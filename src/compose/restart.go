@@ -0,0 +1,134 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"compose/util"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// RestartOptions configures RestartAction.
+type RestartOptions struct {
+	// Services restricts the restart to the given service names; empty means all.
+	Services []string
+	// Timeout is passed to the Docker stop call that precedes each start.
+	Timeout time.Duration
+	// Parallelism bounds how many containers are restarted at once; 0 means unlimited.
+	Parallelism int
+}
+
+// RestartAction implements 'rocker-compose restart'
+//
+// Unlike RunAction it does not run a diff against the manifest - it simply
+// restarts the containers that already belong to the namespace, preserving
+// their ids and any sticky state docker keeps around them.
+func (compose *Compose) RestartAction(opts RestartOptions) error {
+	actual, err := compose.client.GetContainers()
+	if err != nil {
+		return fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	containers := filterContainersByNamespaceAndServices(actual, compose.Manifest.Namespace, opts.Services)
+	if len(containers) == 0 {
+		log.Infof("No containers found for namespace %s", compose.Manifest.Namespace)
+		return nil
+	}
+
+	executionPlan := make([]Action, len(containers))
+	for i, c := range containers {
+		executionPlan[i] = &restartContainer{
+			container: c,
+			timeout:   opts.Timeout,
+		}
+	}
+	compose.executionPlan = executionPlan
+
+	if compose.DryRun {
+		if err := NewDryRunner().Run(executionPlan); err != nil {
+			return fmt.Errorf("Execution failed with, error: %s", err)
+		}
+		return nil
+	}
+
+	maxInFlight := opts.Parallelism
+	if maxInFlight <= 0 || maxInFlight > len(containers) {
+		maxInFlight = len(containers)
+	}
+
+	wg := util.NewBoundedErrorWaitGroup(len(executionPlan), maxInFlight)
+	for _, action := range executionPlan {
+		a := action.(*restartContainer)
+		wg.Go(func() error {
+			return a.Execute(compose.client)
+		})
+	}
+
+	if err := wg.Wait(); err != nil {
+		return fmt.Errorf("Execution failed with, error: %s", err)
+	}
+
+	strContainers := []string{}
+	for _, c := range containers {
+		strContainers = append(strContainers, c.Name.String())
+	}
+	log.Infof("OK, containers are restarted: %s", strings.Join(strContainers, ", "))
+
+	return nil
+}
+
+// restartContainer is an Action that stops and re-starts an existing
+// container in place, without removing or re-creating it.
+type restartContainer struct {
+	container *Container
+	timeout   time.Duration
+}
+
+// String returns a human readable representation of the action, used by DryRunner.
+func (a *restartContainer) String() string {
+	return fmt.Sprintf("Restart container %s", a.container.Name)
+}
+
+// Execute implements Action. RestartAction calls this directly, bounded by
+// a util.BoundedErrorWaitGroup, instead of going through the generic Runner,
+// since it needs to honor RestartOptions.Parallelism.
+func (a *restartContainer) Execute(client Client) error {
+	if err := client.StopContainer(a.container, a.timeout); err != nil {
+		return fmt.Errorf("failed to stop container %s, error: %s", a.container.Name, err)
+	}
+	if err := client.StartContainer(a.container); err != nil {
+		return fmt.Errorf("failed to start container %s, error: %s", a.container.Name, err)
+	}
+	return nil
+}
+
+// Describe implements Describer, for --dry-run --format=json/yaml output.
+func (a *restartContainer) Describe() PlanAction {
+	return PlanAction{
+		Type:   "restart",
+		Reason: "restart_requested",
+		Container: PlanContainerRef{
+			Name:  a.container.Name.String(),
+			Id:    a.container.Id,
+			Image: a.container.Image,
+		},
+	}
+}
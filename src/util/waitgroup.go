@@ -16,9 +16,8 @@
 
 package util
 
-// TODO: document and write tests
-
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -79,3 +78,76 @@ func (wg *ErrorWaitGroup) WaitFor(timeout time.Duration) error {
 	}
 	return nil
 }
+
+// WaitCancel is like WaitFor, but bails out when ctx is done instead of
+// after a fixed timeout, returning ctx.Err() in that case.
+func (wg *ErrorWaitGroup) WaitCancel(ctx context.Context) error {
+	n := cap(wg.ch)
+	if n == 0 {
+		return nil
+	}
+	for {
+		select {
+		case err := <-wg.ch:
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if n--; n == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// BoundedErrorWaitGroup is like ErrorWaitGroup, but caps the number of
+// funcs running at once instead of letting every Go call fire its own
+// goroutine immediately. Useful for fanning out work (pulls, per-container
+// steps) without opening an unbounded number of connections/goroutines.
+type BoundedErrorWaitGroup struct {
+	wg  *ErrorWaitGroup
+	sem chan struct{}
+}
+
+// NewBoundedErrorWaitGroup makes a BoundedErrorWaitGroup expecting total
+// calls to Go, running at most maxInFlight of them concurrently. A
+// maxInFlight <= 0 means unlimited, same as total.
+func NewBoundedErrorWaitGroup(total, maxInFlight int) *BoundedErrorWaitGroup {
+	if maxInFlight <= 0 || maxInFlight > total {
+		maxInFlight = total
+	}
+	return &BoundedErrorWaitGroup{
+		wg:  NewErrorWaitGroup(total),
+		sem: make(chan struct{}, maxInFlight),
+	}
+}
+
+// Go acquires a semaphore slot and runs fn in a new goroutine, releasing the
+// slot and reporting fn's error to the wait group when it returns.
+func (wg *BoundedErrorWaitGroup) Go(fn func() error) {
+	wg.sem <- struct{}{}
+	go func() {
+		defer func() { <-wg.sem }()
+		wg.wg.Done(fn())
+	}()
+}
+
+// Wait blocks until every Go call has completed and returns the first error, if any.
+func (wg *BoundedErrorWaitGroup) Wait() error {
+	return wg.wg.Wait()
+}
+
+// WaitFor is like Wait, but fails with a timeout error once timeout passes
+// without another Go call completing, and returns the first error as soon
+// as it arrives instead of after every call has finished.
+func (wg *BoundedErrorWaitGroup) WaitFor(timeout time.Duration) error {
+	return wg.wg.WaitFor(timeout)
+}
+
+// WaitCancel is like Wait, but bails out when ctx is done instead of waiting
+// for every Go call to finish, returning ctx.Err() in that case.
+func (wg *BoundedErrorWaitGroup) WaitCancel(ctx context.Context) error {
+	return wg.wg.WaitCancel(ctx)
+}
@@ -0,0 +1,97 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBoundedErrorWaitGroup_ZeroSize(t *testing.T) {
+	wg := NewBoundedErrorWaitGroup(0, 0)
+	if err := wg.Wait(); err != nil {
+		t.Fatalf("expected nil error for zero-size wait group, got %s", err)
+	}
+}
+
+func TestBoundedErrorWaitGroup_WaitFor_Timeout(t *testing.T) {
+	wg := NewBoundedErrorWaitGroup(1, 1)
+	wg.Go(func() error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	if err := wg.WaitFor(10 * time.Millisecond); err == nil {
+		t.Fatal("expected a timeout error, got nil")
+	}
+}
+
+func TestBoundedErrorWaitGroup_WaitCancel(t *testing.T) {
+	wg := NewBoundedErrorWaitGroup(1, 1)
+	wg.Go(func() error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := wg.WaitCancel(ctx); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestBoundedErrorWaitGroup_FirstErrorWins(t *testing.T) {
+	wg := NewBoundedErrorWaitGroup(3, 3)
+	wantErr := errors.New("boom")
+
+	wg.Go(func() error { return nil })
+	wg.Go(func() error { return wantErr })
+	wg.Go(func() error { return nil })
+
+	if err := wg.Wait(); err != wantErr {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+}
+
+func TestBoundedErrorWaitGroup_MaxInFlightOne_IsSerial(t *testing.T) {
+	wg := NewBoundedErrorWaitGroup(3, 1)
+
+	var inFlight int32
+	var maxObserved int32
+
+	for i := 0; i < 3; i++ {
+		wg.Go(func() error {
+			n := atomic.AddInt32(&inFlight, 1)
+			if n > atomic.LoadInt32(&maxObserved) {
+				atomic.StoreInt32(&maxObserved, n)
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+			return nil
+		})
+	}
+
+	if err := wg.Wait(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if maxObserved != 1 {
+		t.Fatalf("expected at most 1 goroutine in flight, observed %d", maxObserved)
+	}
+}